@@ -0,0 +1,264 @@
+// Copyright (c) 2014, David Kitchen <david@buro9.com>
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice, this
+//   list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// * Neither the name of the organisation (Microcosm) nor the names of its
+//   contributors may be used to endorse or promote products derived from
+//   this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package bluemonday
+
+import (
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// autoLinkURL matches a bare http(s) URL inside a text node. It's
+// deliberately conservative (no bare "www." and no scheme-less matching)
+// to avoid turning ordinary prose containing a colon into a link, and
+// trims common trailing punctuation so "see https://example.com." doesn't
+// swallow the full stop into the href.
+var autoLinkURL = regexp.MustCompile(`https?://[^\s<>"']+[^\s<>"'.,;:!?)]`)
+
+// autoLinkMention matches an "@user" or "@user@host" handle, the latter
+// being the ActivityPub/Mastodon-style fully qualified form. The regex
+// alone cannot tell a handle at the start of a word from one in the
+// middle of it (e.g. the "@example" inside "jane@example.com"), so every
+// match is additionally checked against isMentionBoundary before it is
+// linked.
+var autoLinkMention = regexp.MustCompile(`@[a-zA-Z0-9_]+(?:@[a-zA-Z0-9.-]+)?`)
+
+// isMentionBoundary reports whether a match of autoLinkMention starting at
+// idx in text begins a genuine handle rather than being carved out of the
+// middle of a longer token such as an email address - "contact
+// jane@example.com" must not have "@example" linked. It requires the
+// character immediately before the match, if any, to not be a letter,
+// digit, underscore, "@" or "." (the characters that can precede the "@"
+// of an email local-part or a chained mention host).
+func isMentionBoundary(text string, idx int) bool {
+	if idx == 0 {
+		return true
+	}
+	r, _ := utf8.DecodeLastRuneInString(text[:idx])
+	return !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '@' || r == '.')
+}
+
+// autoLinkHashtag matches a "#tag" hashtag: a leading "#" followed by
+// letters, numbers or underscores.
+var autoLinkHashtag = regexp.MustCompile(`#[a-zA-Z0-9_]+`)
+
+// autoLinkSkipElements is the set of elements whose text content must
+// never be auto-linkified: "a" (to avoid nested/double links) and "code"/
+// "pre" (where "@" and "#" are usually meaningful syntax, not social
+// handles or hashtags).
+var autoLinkSkipElements = map[string]bool{
+	"a":    true,
+	"code": true,
+	"pre":  true,
+}
+
+// MentionResolverFunc resolves an "@handle" found in text to the href it
+// should link to. ok=false leaves the handle as plain text.
+type MentionResolverFunc func(handle string) (href string, ok bool)
+
+// HashtagResolverFunc resolves a "#tag" found in text to the href it
+// should link to. ok=false leaves the tag as plain text.
+type HashtagResolverFunc func(tag string) (href string, ok bool)
+
+// AutoLinkURLs turns bare http(s) URLs found in text nodes into "a"
+// anchors, skipping text already inside "a", "code" and "pre" elements.
+// Anchors created this way are passed through the same URL scheme
+// allowlist and rel="nofollow" handling as author-supplied links (see
+// AllowStandardURLs); a URL the policy wouldn't otherwise allow is left as
+// plain text rather than linked.
+func (p *Policy) AutoLinkURLs() *Policy {
+	p.autoLinkURLs = true
+	return p
+}
+
+// AutoLinkMentions turns "@handle" and "@handle@host" text into "a"
+// anchors using resolve to look up the href, skipping unresolved handles,
+// hrefs the policy's URL validation rejects, and text already inside "a",
+// "code" and "pre".
+func (p *Policy) AutoLinkMentions(resolve MentionResolverFunc) *Policy {
+	p.autoLinkMentions = resolve
+	return p
+}
+
+// AutoLinkHashtags turns "#tag" text into "a" anchors using resolve to
+// look up the href, skipping unresolved tags, hrefs the policy's URL
+// validation rejects, and text already inside "a", "code" and "pre".
+func (p *Policy) AutoLinkHashtags(resolve HashtagResolverFunc) *Policy {
+	p.autoLinkHashtags = resolve
+	return p
+}
+
+// autoLinkEnabled reports whether any of AutoLinkURLs/AutoLinkMentions/
+// AutoLinkHashtags has been configured on the policy, and so whether text
+// nodes need to be run through autoLinkText at all.
+func (p *Policy) autoLinkEnabled() bool {
+	return p.autoLinkURLs || p.autoLinkMentions != nil || p.autoLinkHashtags != nil
+}
+
+// inAutoLinkSkipElement reports whether any element in parents is one of
+// autoLinkSkipElements, meaning a text node found there must be left
+// alone.
+func inAutoLinkSkipElement(parents []string) bool {
+	for _, tag := range parents {
+		if autoLinkSkipElements[tag] {
+			return true
+		}
+	}
+	return false
+}
+
+// autoLinkTokens runs text through autoLinkText and expands the result
+// into the html.Token stream the tokenizer loop works with: a plain
+// TextToken for an unlinked segment, or a start tag/TextToken/end tag
+// "a" triple - with rel="nofollow" added under the same
+// RequireNoFollowOnLinks policy as any other link - for a linked one.
+func (p *Policy) autoLinkTokens(text string) []html.Token {
+	segments := p.autoLinkText(text)
+
+	var tokens []html.Token
+	for _, seg := range segments {
+		if seg.href == "" {
+			if seg.text == "" {
+				continue
+			}
+			tokens = append(tokens, html.Token{Type: html.TextToken, Data: seg.text})
+			continue
+		}
+
+		attrs := []html.Attribute{{Key: "href", Val: seg.href}}
+		if p.requireNoFollow {
+			attrs = ensureRelNoFollow(attrs)
+		}
+		tokens = append(tokens,
+			html.Token{Type: html.StartTagToken, Data: "a", Attr: attrs},
+			html.Token{Type: html.TextToken, Data: seg.text},
+			html.Token{Type: html.EndTagToken, Data: "a"},
+		)
+	}
+	return tokens
+}
+
+// autoLinkSegment is either plain text (href == "") or text that a caller
+// should wrap in an "a href=href" anchor.
+type autoLinkSegment struct {
+	text string
+	href string
+}
+
+// autoLinkText rewrites a single text node's data into a, possibly empty,
+// mixture of plain text and linked segments, applying whichever of
+// AutoLinkURLs/AutoLinkMentions/AutoLinkHashtags the policy has enabled, in
+// that order of precedence so that, for example, a "@" in a URL's query
+// string is not separately considered for mention-linking.
+func (p *Policy) autoLinkText(text string) []autoLinkSegment {
+	segments := []autoLinkSegment{{text: text}}
+
+	if p.autoLinkURLs {
+		segments = splitOnMatches(segments, autoLinkURL, nil, func(match string) *autoLinkSegment {
+			href, ok := p.validURL(match)
+			if !ok {
+				return nil
+			}
+			return &autoLinkSegment{text: match, href: href}
+		})
+	}
+
+	if p.autoLinkMentions != nil {
+		segments = splitOnMatches(segments, autoLinkMention, isMentionBoundary, func(match string) *autoLinkSegment {
+			href, ok := p.autoLinkMentions(match)
+			if !ok {
+				return nil
+			}
+			if href, ok = p.validURL(href); !ok {
+				return nil
+			}
+			return &autoLinkSegment{text: match, href: href}
+		})
+	}
+
+	if p.autoLinkHashtags != nil {
+		segments = splitOnMatches(segments, autoLinkHashtag, nil, func(match string) *autoLinkSegment {
+			href, ok := p.autoLinkHashtags(match)
+			if !ok {
+				return nil
+			}
+			if href, ok = p.validURL(href); !ok {
+				return nil
+			}
+			return &autoLinkSegment{text: match, href: href}
+		})
+	}
+
+	return segments
+}
+
+// splitOnMatches applies re to the text of every plain-text segment (one
+// not already linked by an earlier pass) and replaces each match with the
+// segment resolve returns for it, or leaves the match as plain text if
+// resolve returns nil. If isBoundary is non-nil, a match is skipped
+// entirely (left untouched, as part of the surrounding plain text) when
+// isBoundary reports that it doesn't begin at a genuine token boundary.
+func splitOnMatches(segments []autoLinkSegment, re *regexp.Regexp, isBoundary func(text string, idx int) bool, resolve func(string) *autoLinkSegment) []autoLinkSegment {
+	var out []autoLinkSegment
+
+	for _, seg := range segments {
+		if seg.href != "" {
+			out = append(out, seg)
+			continue
+		}
+
+		last := 0
+		for _, loc := range re.FindAllStringIndex(seg.text, -1) {
+			if isBoundary != nil && !isBoundary(seg.text, loc[0]) {
+				continue
+			}
+
+			if loc[0] > last {
+				out = append(out, autoLinkSegment{text: seg.text[last:loc[0]]})
+			}
+
+			match := seg.text[loc[0]:loc[1]]
+			if linked := resolve(match); linked != nil {
+				out = append(out, *linked)
+			} else {
+				out = append(out, autoLinkSegment{text: match})
+			}
+
+			last = loc[1]
+		}
+		if last < len(seg.text) {
+			out = append(out, autoLinkSegment{text: seg.text[last:]})
+		}
+	}
+
+	return out
+}
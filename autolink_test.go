@@ -0,0 +1,82 @@
+package bluemonday
+
+import "testing"
+
+func TestIsMentionBoundary(t *testing.T) {
+	tests := []struct {
+		text string
+		idx  int
+		want bool
+	}{
+		{"@alice", 0, true},
+		{"hi @alice", 3, true},
+		{"jane@example.com", 4, false},
+		{"see @bob@example.social please", 4, true},
+		{"a.@bob", 2, false},
+	}
+
+	for _, tt := range tests {
+		if got := isMentionBoundary(tt.text, tt.idx); got != tt.want {
+			t.Errorf("isMentionBoundary(%q, %d) = %v, want %v", tt.text, tt.idx, got, tt.want)
+		}
+	}
+}
+
+func TestSplitOnMatchesSkipsNonBoundaryMentions(t *testing.T) {
+	resolved := map[string]string{
+		"@alice": "https://example.social/@alice",
+	}
+	resolve := func(match string) *autoLinkSegment {
+		href, ok := resolved[match]
+		if !ok {
+			return nil
+		}
+		return &autoLinkSegment{text: match, href: href}
+	}
+
+	segments := splitOnMatches(
+		[]autoLinkSegment{{text: "contact jane@example.com or @alice"}},
+		autoLinkMention,
+		isMentionBoundary,
+		resolve,
+	)
+
+	var rebuilt string
+	var linkedHandles []string
+	for _, seg := range segments {
+		rebuilt += seg.text
+		if seg.href != "" {
+			linkedHandles = append(linkedHandles, seg.text)
+		}
+	}
+
+	if rebuilt != "contact jane@example.com or @alice" {
+		t.Errorf("rebuilt text = %q, want original text unchanged aside from linking", rebuilt)
+	}
+	if len(linkedHandles) != 1 || linkedHandles[0] != "@alice" {
+		t.Errorf("expected only @alice to be linked, got %v", linkedHandles)
+	}
+}
+
+func TestSplitOnMatchesWithoutBoundaryCheckLinksEveryMatch(t *testing.T) {
+	resolve := func(match string) *autoLinkSegment {
+		return &autoLinkSegment{text: match, href: "https://example.com/tags/" + match[1:]}
+	}
+
+	segments := splitOnMatches(
+		[]autoLinkSegment{{text: "see #golang now"}},
+		autoLinkHashtag,
+		nil,
+		resolve,
+	)
+
+	var linked int
+	for _, seg := range segments {
+		if seg.href != "" {
+			linked++
+		}
+	}
+	if linked != 1 {
+		t.Errorf("expected exactly one linked hashtag segment, got %d", linked)
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright (c) 2014, David Kitchen <david@buro9.com>
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice, this
+//   list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// * Neither the name of the organisation (Microcosm) nor the names of its
+//   contributors may be used to endorse or promote products derived from
+//   this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package bluemonday
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// CSSColor matches hex, rgb()/rgba()/hsl()/hsla() and keyword CSS colour
+// values. It does not attempt to validate that a keyword is a real CSS
+// colour name, only that the value contains nothing but the characters a
+// colour value is built from.
+// https://developer.mozilla.org/en-US/docs/Web/CSS/color_value
+var CSSColor = regexp.MustCompile(
+	`(?i)^(#[0-9a-f]{3,8}|rgba?\([0-9.%,\s]+\)|hsla?\([0-9.%,\s]+\)|[a-z]+)$`,
+)
+
+// cssDisallowedValue matches constructs that are never safe in a CSS
+// declaration value regardless of which property they appear on: IE's
+// legacy expression() script execution, @import (which can pull in and
+// execute further, unreviewed CSS), comments (a classic way to split a
+// disallowed token so a naive string match misses it) and any backslash,
+// which covers both \-escaped characters and the unicode escape tricks
+// historically used to smuggle "javascript:" or "expression(" past filters
+// that only look for the literal text.
+var cssDisallowedValue = regexp.MustCompile(`(?i)expression\s*\(|@import|/\*|\*/|\\`)
+
+// cssURLFuncPrefix matches the "url(" opener of a CSS url(...) value,
+// case-insensitively, with optional whitespace after it.
+var cssURLFuncPrefix = regexp.MustCompile(`(?i)^url\(\s*`)
+
+// cssURLFuncArg extracts the argument of a CSS "url(...)" value, stripping
+// a matching pair of surrounding quotes if present. ok is false when value
+// isn't a url(...) function at all. This is affix-stripping rather than a
+// single regexp match because the argument itself (e.g. a "javascript:"
+// URL with a parenthesised call in it) can legitimately contain the
+// characters that would otherwise have to delimit it, and Go's RE2 engine
+// has no backreference to pair an opening quote with its matching close.
+func cssURLFuncArg(value string) (string, bool) {
+	loc := cssURLFuncPrefix.FindStringIndex(value)
+	if loc == nil || !strings.HasSuffix(value, ")") {
+		return "", false
+	}
+
+	arg := strings.TrimSpace(value[loc[1] : len(value)-1])
+	if len(arg) >= 2 {
+		if (arg[0] == '"' && arg[len(arg)-1] == '"') || (arg[0] == '\'' && arg[len(arg)-1] == '\'') {
+			arg = arg[1 : len(arg)-1]
+		}
+	}
+	return arg, true
+}
+
+// stylePolicy is the style-attribute equivalent of attrPolicy: the
+// compiled pattern a property's value must match. A nil regexp means any
+// value that survives the built-in safety checks (cssDisallowedValue, the
+// CSS custom property/var() ban, and URL scheme validation) is allowed.
+type stylePolicy struct {
+	regexp *regexp.Regexp
+}
+
+// cssPropertyPolicyBuilder is returned by AllowStyles and restricts a group
+// of CSS properties to a value pattern and/or a set of elements, mirroring
+// the attrPolicyBuilder returned by AllowAttrs.
+type cssPropertyPolicyBuilder struct {
+	p          *Policy
+	properties []string
+	regexp     *regexp.Regexp
+}
+
+// AllowStyles takes one or more CSS property names (e.g. "color",
+// "text-align") and returns a builder used to restrict the values they may
+// take with .Matching(), and the elements they apply to with
+// .OnElements()/.Globally(). A property that is never passed to AllowStyles
+// is stripped from any "style" attribute regardless of its value.
+func (p *Policy) AllowStyles(properties ...string) *cssPropertyPolicyBuilder {
+	return &cssPropertyPolicyBuilder{
+		p:          p,
+		properties: properties,
+	}
+}
+
+// Matching allows the property values to be matched against a regular
+// expression.
+func (c *cssPropertyPolicyBuilder) Matching(regex *regexp.Regexp) *cssPropertyPolicyBuilder {
+	c.regexp = regex
+	return c
+}
+
+// OnElements will bind the declared CSS properties to a given element or
+// elements and return the updated policy. Element and property names are
+// lowercased, matching the case-insensitivity of HTML/CSS and the
+// convention the rest of the allowlist machinery (e.g. AllowAttrs) uses,
+// so that e.g. AllowStyles("Color").OnElements("DIV") still matches.
+func (c *cssPropertyPolicyBuilder) OnElements(elements ...string) *Policy {
+	for _, element := range elements {
+		element = strings.ToLower(element)
+		if c.p.elsAndStyles[element] == nil {
+			c.p.elsAndStyles[element] = make(map[string]stylePolicy)
+		}
+		for _, property := range c.properties {
+			c.p.elsAndStyles[element][strings.ToLower(property)] = stylePolicy{regexp: c.regexp}
+		}
+	}
+	return c.p
+}
+
+// Globally will bind the declared CSS properties to all elements that the
+// policy otherwise allows and return the updated policy. Property
+// names are lowercased for the same reason as in OnElements.
+func (c *cssPropertyPolicyBuilder) Globally() *Policy {
+	for _, property := range c.properties {
+		property = strings.ToLower(property)
+		c.p.globalStyles[property] = stylePolicy{regexp: c.regexp}
+	}
+	return c.p
+}
+
+// sanitizeStyles takes the raw value of a "style" attribute found on
+// element and returns a re-serialised declaration list containing only the
+// declarations that were allowed for that element (via OnElements or
+// Globally), with their values validated. If nothing survives, ok is false
+// and the caller should drop the "style" attribute entirely.
+func (p *Policy) sanitizeStyles(style string, element string) (string, bool) {
+	var kept []string
+
+	for _, decl := range strings.Split(style, ";") {
+		decl = strings.TrimSpace(decl)
+		if decl == "" {
+			continue
+		}
+
+		parts := strings.SplitN(decl, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		property := strings.ToLower(strings.TrimSpace(parts[0]))
+		value := strings.TrimSpace(parts[1])
+		if property == "" || value == "" {
+			continue
+		}
+
+		// CSS custom properties and the var() function that consumes them
+		// are a documented way to smuggle a disallowed value through a
+		// property whose own regexp would otherwise reject it.
+		if strings.HasPrefix(property, "--") || strings.Contains(strings.ToLower(value), "var(") {
+			continue
+		}
+
+		if cssDisallowedValue.MatchString(value) {
+			continue
+		}
+
+		policy, ok := p.elsAndStyles[element][property]
+		if !ok {
+			policy, ok = p.globalStyles[property]
+		}
+		if !ok {
+			continue
+		}
+
+		if arg, isURLFunc := cssURLFuncArg(value); isURLFunc {
+			resolved, ok := p.validURL(arg)
+			if !ok {
+				continue
+			}
+			value = fmt.Sprintf("url(%q)", resolved)
+		} else if strings.Contains(strings.ToLower(value), "url(") {
+			// A url(...) that isn't the whole value can't be validated
+			// above, so the declaration is dropped.
+			continue
+		}
+
+		if policy.regexp != nil && !policy.regexp.MatchString(value) {
+			continue
+		}
+
+		kept = append(kept, property+": "+value)
+	}
+
+	if len(kept) == 0 {
+		return "", false
+	}
+	return strings.Join(kept, "; "), true
+}
@@ -0,0 +1,65 @@
+package bluemonday
+
+import "testing"
+
+func newStylePolicy() *Policy {
+	return &Policy{
+		elsAndStyles: map[string]map[string]stylePolicy{},
+		globalStyles: map[string]stylePolicy{},
+	}
+}
+
+func TestAllowStylesOnElementsIsCaseInsensitive(t *testing.T) {
+	p := newStylePolicy()
+	p.AllowStyles("Color").OnElements("DIV")
+
+	out, ok := p.sanitizeStyles("color: red", "div")
+	if !ok {
+		t.Fatalf("expected color to be allowed on div after registering Color on DIV, got ok=false")
+	}
+	if out != "color: red" {
+		t.Errorf("sanitizeStyles() = %q, want %q", out, "color: red")
+	}
+}
+
+func TestAllowStylesGloballyIsCaseInsensitive(t *testing.T) {
+	p := newStylePolicy()
+	p.AllowStyles("Text-Align").Globally()
+
+	out, ok := p.sanitizeStyles("text-align: center", "span")
+	if !ok {
+		t.Fatalf("expected text-align to be allowed globally after registering Text-Align, got ok=false")
+	}
+	if out != "text-align: center" {
+		t.Errorf("sanitizeStyles() = %q, want %q", out, "text-align: center")
+	}
+}
+
+func TestSanitizeStylesRejectsUnsafeValues(t *testing.T) {
+	p := newStylePolicy()
+	p.AllowStyles("width").Globally()
+
+	tests := []string{
+		"width: expression(alert(1))",
+		"width: url(javascript:alert(1))",
+	}
+
+	for _, style := range tests {
+		if _, ok := p.sanitizeStyles(style, "div"); ok {
+			t.Errorf("sanitizeStyles(%q) unexpectedly allowed", style)
+		}
+	}
+}
+
+func TestSanitizeStylesStripsOnlyTheUnsafeDeclaration(t *testing.T) {
+	p := newStylePolicy()
+	p.AllowStyles("width").Globally()
+
+	out, ok := p.sanitizeStyles("width: 1px; @import url(evil.css)", "div")
+	if !ok {
+		t.Fatalf("expected the safe width declaration to survive, got ok=false")
+	}
+	if out != "width: 1px" {
+		t.Errorf("sanitizeStyles() = %q, want %q", out, "width: 1px")
+	}
+}
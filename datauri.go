@@ -0,0 +1,111 @@
+// Copyright (c) 2014, David Kitchen <david@buro9.com>
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice, this
+//   list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// * Neither the name of the organisation (Microcosm) nor the names of its
+//   contributors may be used to endorse or promote products derived from
+//   this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package bluemonday
+
+import (
+	"encoding/base64"
+	"regexp"
+	"strings"
+)
+
+// dataURIPattern matches a "data:<mime>;base64,<payload>" URI and captures
+// the declared MIME type and the base64 payload. Any data URI that doesn't
+// declare base64 encoding explicitly, or carries additional parameters such
+// as a charset, is rejected rather than guessed at.
+var dataURIPattern = regexp.MustCompile(`(?i)^data:([a-z0-9.+-]+/[a-z0-9.+-]+);base64,([a-zA-Z0-9+/=]+)$`)
+
+// DefaultDataURIImageTypes is the MIME allowlist AllowDataURIImages falls
+// back to when called with no explicit mimeTypes.
+var DefaultDataURIImageTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+
+// AllowDataURIImages permits "data:" URIs wherever the URL policy is
+// consulted (any attribute listed in urlAttrs, such as img.src or
+// video.poster, and CSS url(...) values) provided the declared MIME type is one of
+// mimeTypes (DefaultDataURIImageTypes if none are given), the payload is
+// valid base64, and it decodes to no more than maxBytes. A maxBytes of 0
+// means the decoded size is not checked.
+//
+// AllowImages does not call this itself: "data:" is deliberately left out
+// of the scheme allowlist it configures, because an unconstrained data URI
+// is a way to smuggle an arbitrarily large, and arbitrarily typed, binary
+// payload past a reviewer who is only looking at the surrounding markup.
+func (p *Policy) AllowDataURIImages(maxBytes int64, mimeTypes ...string) *Policy {
+	if len(mimeTypes) == 0 {
+		mimeTypes = DefaultDataURIImageTypes
+	}
+
+	p.allowDataURIImages = true
+	p.dataURIMaxBytes = maxBytes
+	p.dataURIMimeTypes = make(map[string]bool, len(mimeTypes))
+	for _, mimeType := range mimeTypes {
+		p.dataURIMimeTypes[strings.ToLower(mimeType)] = true
+	}
+
+	return p
+}
+
+// validDataURI is consulted from the same URL validation path as
+// AllowURLSchemes whenever a candidate URL starts with "data:". It returns
+// the original URI unmodified and ok=true only once the MIME type, base64
+// payload and decoded size have all passed AllowDataURIImages' constraints.
+func (p *Policy) validDataURI(rawurl string) (string, bool) {
+	if !p.allowDataURIImages {
+		return "", false
+	}
+
+	m := dataURIPattern.FindStringSubmatch(rawurl)
+	if m == nil {
+		return "", false
+	}
+
+	mimeType, payload := strings.ToLower(m[1]), m[2]
+	if !p.dataURIMimeTypes[mimeType] {
+		return "", false
+	}
+
+	// Reject on the encoded length, before decoding, so that an
+	// oversized payload can't force an allocation proportional to its
+	// size just to find out it should have been rejected. Base64 encodes
+	// 3 bytes as 4, so the decoded size is at most len(payload)*3/4.
+	if p.dataURIMaxBytes > 0 && int64(len(payload))*3/4 > p.dataURIMaxBytes {
+		return "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", false
+	}
+
+	if p.dataURIMaxBytes > 0 && int64(len(decoded)) > p.dataURIMaxBytes {
+		return "", false
+	}
+
+	return rawurl, true
+}
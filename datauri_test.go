@@ -0,0 +1,60 @@
+package bluemonday
+
+import "testing"
+
+func TestValidDataURI(t *testing.T) {
+	onePixelPNG := "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAQAAAC1HAwCAAAAC0lEQVR42mNk+A8AAQUBAScY42YAAAAASUVORK5CYII="
+
+	tests := []struct {
+		name   string
+		policy *Policy
+		rawurl string
+		wantOK bool
+	}{
+		{
+			name:   "allowed mime under the size cap",
+			policy: (&Policy{}).AllowDataURIImages(1 << 20),
+			rawurl: onePixelPNG,
+			wantOK: true,
+		},
+		{
+			name:   "mime type not in the allowlist",
+			policy: (&Policy{}).AllowDataURIImages(1<<20, "image/webp"),
+			rawurl: onePixelPNG,
+			wantOK: false,
+		},
+		{
+			name:   "payload exceeds maxBytes",
+			policy: (&Policy{}).AllowDataURIImages(8),
+			rawurl: onePixelPNG,
+			wantOK: false,
+		},
+		{
+			name:   "not valid base64",
+			policy: (&Policy{}).AllowDataURIImages(1 << 20),
+			rawurl: "data:image/png;base64,not-valid-base64!!",
+			wantOK: false,
+		},
+		{
+			name:   "missing the base64 declaration",
+			policy: (&Policy{}).AllowDataURIImages(1 << 20),
+			rawurl: "data:image/png,plainpayload",
+			wantOK: false,
+		},
+		{
+			name:   "feature not enabled",
+			policy: &Policy{},
+			rawurl: onePixelPNG,
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ok := tt.policy.validDataURI(tt.rawurl)
+			if ok != tt.wantOK {
+				t.Errorf("validDataURI(%q) ok = %v, want %v", tt.rawurl, ok, tt.wantOK)
+			}
+		})
+	}
+}
@@ -88,7 +88,7 @@ var (
 	// Whole numbers or %. Used predominantly as units of measurement in width
 	// and height attributes
 	// https://developer.mozilla.org/en-US/docs/Web/HTML/Element/img#attr-height
-	NumberOrPercent = regexp.MustCompile(`[0-9]+%?`)
+	NumberOrPercent = regexp.MustCompile(`^[0-9]+%?$`)
 
 	// Any block of text in an attribute such as *.'title', img.alt, etc
 	// https://developer.mozilla.org/en-US/docs/Web/HTML/Global_attributes#attr-title
@@ -139,6 +139,41 @@ func (p *Policy) AllowStandardAttributes() {
 	p.AllowAttrs("title").Matching(Paragraph).Globally()
 }
 
+// AllowStandardAttributesNamespaced is identical to AllowStandardAttributes
+// except that "id" (and anything that references an "id", see NamespaceIDs)
+// is namespaced with prefix rather than being allowed to pass through
+// unchanged. Use this instead of AllowStandardAttributes when the sanitized
+// fragment will be embedded inside a larger page whose own ids, CSS
+// selectors or ARIA references should not be able to collide with
+// user-supplied ones.
+func (p *Policy) AllowStandardAttributesNamespaced(prefix string) {
+	p.AllowStandardAttributes()
+	p.NamespaceIDs(prefix)
+}
+
+// AllowStandardStyles enables a conservative set of typographic and colour
+// CSS properties on the "style" attribute of any element the policy
+// otherwise allows.
+func (p *Policy) AllowStandardStyles() {
+	p.AllowStyles("color", "background-color").Matching(CSSColor).Globally()
+
+	p.AllowStyles("text-align").Matching(
+		regexp.MustCompile(`(?i)center|justify|left|right`),
+	).Globally()
+
+	p.AllowStyles("font-weight").Matching(
+		regexp.MustCompile(`(?i)normal|bold|bolder|lighter|[1-9]00`),
+	).Globally()
+
+	p.AllowStyles("font-style").Matching(
+		regexp.MustCompile(`(?i)normal|italic|oblique`),
+	).Globally()
+
+	p.AllowStyles("text-decoration").Matching(
+		regexp.MustCompile(`(?i)none|underline|overline|line-through`),
+	).Globally()
+}
+
 // AllowImages enables the img element and some popular attributes. It will also
 // ensure that URL values are parseable
 func (p *Policy) AllowImages() {
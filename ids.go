@@ -0,0 +1,175 @@
+// Copyright (c) 2014, David Kitchen <david@buro9.com>
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice, this
+//   list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// * Neither the name of the organisation (Microcosm) nor the names of its
+//   contributors may be used to endorse or promote products derived from
+//   this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package bluemonday
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// idRefAttrs lists the attributes (other than "id" itself and the
+// special-cased anchor "href") whose value is a single id that references
+// an element elsewhere in the same document. When NamespaceIDs is enabled
+// these are rewritten in lock-step with the ids they point at so that
+// intra-document references keep resolving.
+var idRefAttrs = map[string]bool{
+	"for": true,
+}
+
+// idRefListAttrs lists the attributes whose value is a space-separated
+// list of IDREFS rather than a single id - https://html.spec.whatwg.org/
+// multipage/common-microsyntaxes.html#set-of-space-separated-tokens and
+// https://www.w3.org/TR/wai-aria-1.1/#valuetype_idref_list. Each token in
+// the list is namespaced (or dropped) independently.
+var idRefListAttrs = map[string]bool{
+	"headers":          true,
+	"aria-labelledby":  true,
+	"aria-describedby": true,
+}
+
+// idFragmentHref matches an href value that is a same-document fragment
+// link, e.g. href="#section-2", as opposed to an absolute or relative URL.
+var idFragmentHref = regexp.MustCompile(`^#(.+)$`)
+
+// NamespaceIDs enables ID namespacing on this policy. Once enabled, every
+// "id" attribute that Sanitize lets through is rewritten to prefix+value,
+// and every attribute that references an id elsewhere in the document
+// ("for", "headers", "aria-labelledby", "aria-describedby", and a
+// fragment-only "href") is rewritten to match, so that intra-document
+// references keep working after prefixing.
+//
+// This closes the gap left by AllowStandardAttributes allowing "id"
+// unprefixed: without namespacing, sanitized user content can supply an id
+// that collides with one the surrounding page's JavaScript, CSS or ARIA
+// references expect to be its own.
+//
+// Because rewriting "for"/"headers"/etc. requires knowing every id defined
+// anywhere in the document, not just the element currently being
+// processed, the rewrite runs as a second pass over the whole token stream
+// collected by a single Sanitize/SanitizeBytes/SanitizeReader call, after
+// allowlist filtering and before the tokens are re-serialised.
+func (p *Policy) NamespaceIDs(prefix string) *Policy {
+	p.requireNamespacedIDs = true
+	p.idNamespacePrefix = prefix
+	return p
+}
+
+// DropUnknownIDReferences controls what NamespaceIDs does with an
+// ID-referencing attribute (or a fragment-only href) whose target id is not
+// defined anywhere in the document being sanitized. When true the
+// attribute is dropped; when false (the default) it is namespaced the same
+// as any other reference even though it will not resolve to anything,
+// matching how the attribute would have behaved unprefixed.
+func (p *Policy) DropUnknownIDReferences(drop bool) *Policy {
+	p.dropUnknownIDReferences = drop
+	return p
+}
+
+// namespaceIDTokens performs the rewrite described on NamespaceIDs against
+// the full set of tokens produced for one document. It is called by
+// sanitize once the tokenizer loop has finished and the allowlist has
+// already decided which attributes survive, so it only ever narrows or
+// relabels attributes that were already going to be kept.
+func namespaceIDTokens(tokens []html.Token, prefix string, dropUnknown bool) {
+	defined := make(map[string]bool)
+	for i := range tokens {
+		if tokens[i].Type != html.StartTagToken && tokens[i].Type != html.SelfClosingTagToken {
+			continue
+		}
+		for _, a := range tokens[i].Attr {
+			if a.Key == "id" && a.Val != "" {
+				defined[a.Val] = true
+			}
+		}
+	}
+
+	for i := range tokens {
+		if tokens[i].Type != html.StartTagToken && tokens[i].Type != html.SelfClosingTagToken {
+			continue
+		}
+
+		kept := tokens[i].Attr[:0]
+		for _, a := range tokens[i].Attr {
+			switch {
+			case a.Key == "id":
+				a.Val = prefix + a.Val
+
+			case idRefAttrs[a.Key]:
+				if !defined[a.Val] && dropUnknown {
+					continue
+				}
+				a.Val = prefix + a.Val
+
+			case idRefListAttrs[a.Key]:
+				rewritten, ok := namespaceIDRefList(a.Val, prefix, defined, dropUnknown)
+				if !ok {
+					continue
+				}
+				a.Val = rewritten
+
+			case a.Key == "href":
+				if m := idFragmentHref.FindStringSubmatch(a.Val); m != nil {
+					if !defined[m[1]] && dropUnknown {
+						continue
+					}
+					a.Val = "#" + prefix + m[1]
+				}
+			}
+			kept = append(kept, a)
+		}
+		tokens[i].Attr = kept
+	}
+}
+
+// namespaceIDRefList rewrites each whitespace-separated token in an IDREFS
+// attribute value (e.g. "headers" or "aria-labelledby") independently,
+// since each token is its own reference to a (possibly different) id. A
+// token whose target isn't in defined is dropped if dropUnknown is set,
+// otherwise namespaced the same as any other token. ok is false, meaning
+// the whole attribute should be dropped, only when every token was
+// dropped and nothing is left to keep.
+func namespaceIDRefList(val, prefix string, defined map[string]bool, dropUnknown bool) (string, bool) {
+	tokens := strings.Fields(val)
+	kept := make([]string, 0, len(tokens))
+
+	for _, tok := range tokens {
+		if !defined[tok] && dropUnknown {
+			continue
+		}
+		kept = append(kept, prefix+tok)
+	}
+
+	if len(kept) == 0 {
+		return "", false
+	}
+	return strings.Join(kept, " "), true
+}
@@ -0,0 +1,98 @@
+package bluemonday
+
+import (
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func attr(key, val string) html.Attribute {
+	return html.Attribute{Key: key, Val: val}
+}
+
+func TestNamespaceIDTokensRewritesMultiValuedIDREFS(t *testing.T) {
+	tokens := []html.Token{
+		{Type: html.StartTagToken, Data: "span", Attr: []html.Attribute{attr("id", "h1")}},
+		{Type: html.StartTagToken, Data: "span", Attr: []html.Attribute{attr("id", "h2")}},
+		{Type: html.StartTagToken, Data: "td", Attr: []html.Attribute{attr("headers", "h1 h2")}},
+	}
+
+	namespaceIDTokens(tokens, "ns-", false)
+
+	got := tokens[2].Attr[0].Val
+	want := "ns-h1 ns-h2"
+	if got != want {
+		t.Errorf("headers rewritten to %q, want %q", got, want)
+	}
+}
+
+func TestNamespaceIDTokensDropsOnlyUnknownTokensInList(t *testing.T) {
+	tokens := []html.Token{
+		{Type: html.StartTagToken, Data: "span", Attr: []html.Attribute{attr("id", "h1")}},
+		{Type: html.StartTagToken, Data: "td", Attr: []html.Attribute{attr("headers", "h1 missing")}},
+	}
+
+	namespaceIDTokens(tokens, "ns-", true)
+
+	attrs := tokens[1].Attr
+	if len(attrs) != 1 || attrs[0].Key != "headers" {
+		t.Fatalf("expected headers attribute to survive with only the known id, got %#v", attrs)
+	}
+	if attrs[0].Val != "ns-h1" {
+		t.Errorf("headers = %q, want %q", attrs[0].Val, "ns-h1")
+	}
+}
+
+func TestNamespaceIDTokensDropsWholeListWhenAllUnknown(t *testing.T) {
+	tokens := []html.Token{
+		{Type: html.StartTagToken, Data: "td", Attr: []html.Attribute{attr("headers", "missing1 missing2")}},
+	}
+
+	namespaceIDTokens(tokens, "ns-", true)
+
+	if len(tokens[0].Attr) != 0 {
+		t.Errorf("expected headers attribute to be dropped entirely, got %#v", tokens[0].Attr)
+	}
+}
+
+func TestNamespaceIDTokensFragmentHref(t *testing.T) {
+	tokens := []html.Token{
+		{Type: html.StartTagToken, Data: "span", Attr: []html.Attribute{attr("id", "h1")}},
+		{Type: html.StartTagToken, Data: "a", Attr: []html.Attribute{attr("href", "#h1")}},
+		{Type: html.StartTagToken, Data: "a", Attr: []html.Attribute{attr("href", "#missing")}},
+	}
+
+	namespaceIDTokens(tokens, "ns-", false)
+
+	if got := tokens[1].Attr[0].Val; got != "#ns-h1" {
+		t.Errorf("href to a known id = %q, want %q", got, "#ns-h1")
+	}
+	if got := tokens[2].Attr[0].Val; got != "#ns-missing" {
+		t.Errorf("href to an unknown id with dropUnknown=false = %q, want %q (namespaced, not left unprefixed)", got, "#ns-missing")
+	}
+}
+
+func TestNamespaceIDTokensDropsFragmentHrefToUnknownID(t *testing.T) {
+	tokens := []html.Token{
+		{Type: html.StartTagToken, Data: "a", Attr: []html.Attribute{attr("href", "#missing")}},
+	}
+
+	namespaceIDTokens(tokens, "ns-", true)
+
+	if len(tokens[0].Attr) != 0 {
+		t.Errorf("expected href to an unknown id to be dropped, got %#v", tokens[0].Attr)
+	}
+}
+
+func TestNamespaceIDTokensSingleValuedFor(t *testing.T) {
+	tokens := []html.Token{
+		{Type: html.StartTagToken, Data: "input", Attr: []html.Attribute{attr("id", "name")}},
+		{Type: html.StartTagToken, Data: "label", Attr: []html.Attribute{attr("for", "name")}},
+	}
+
+	namespaceIDTokens(tokens, "ns-", false)
+
+	if tokens[1].Attr[0].Val != "ns-name" {
+		t.Errorf("for = %q, want %q", tokens[1].Attr[0].Val, "ns-name")
+	}
+}
@@ -0,0 +1,55 @@
+package bluemonday
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIframeSrcMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		src     string
+		want    bool
+	}{
+		{"www.youtube.com/embed/*", "https://www.youtube.com/embed/dQw4w9WgXcQ", true},
+		{"www.youtube.com/embed/*", "https://www.youtube.com/embed/dQw4w9WgXcQ/extra", false},
+		{"player.vimeo.com/video/*", "https://player.vimeo.com/video/12345", true},
+		{"codepen.io/*/embed/*", "https://codepen.io/someone/embed/abcdef", true},
+		{"www.youtube.com/embed/*", "https://evil.example/embed/dQw4w9WgXcQ", false},
+		{"www.youtube.com/embed/*", "https://www.youtube.com/watch?v=dQw4w9WgXcQ", false},
+	}
+
+	for _, tt := range tests {
+		if got := iframeSrcMatches(tt.pattern, tt.src); got != tt.want {
+			t.Errorf("iframeSrcMatches(%q, %q) = %v, want %v", tt.pattern, tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeIFrameAttrsDoesNotCombineScriptsWithSameOrigin(t *testing.T) {
+	attrs := sanitizeIFrameAttrs("https://www.youtube.com/embed/dQw4w9WgXcQ", "560", "315")
+
+	var sandbox string
+	for _, a := range attrs {
+		if a.Key == "sandbox" {
+			sandbox = a.Val
+		}
+	}
+
+	if sandbox == "" {
+		t.Fatal("expected a sandbox attribute to be present")
+	}
+	if strings.Contains(sandbox, "allow-same-origin") && strings.Contains(sandbox, "allow-scripts") {
+		t.Errorf("sandbox %q combines allow-scripts with allow-same-origin, which lets framed script remove its own sandbox", sandbox)
+	}
+}
+
+func TestSanitizeIFrameAttrsDropsInvalidDimensions(t *testing.T) {
+	attrs := sanitizeIFrameAttrs("https://www.youtube.com/embed/dQw4w9WgXcQ", "onload=alert(1)", "315")
+
+	for _, a := range attrs {
+		if a.Key == "width" {
+			t.Errorf("expected invalid width to be dropped, got %q", a.Val)
+		}
+	}
+}
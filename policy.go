@@ -0,0 +1,328 @@
+// Copyright (c) 2014, David Kitchen <david@buro9.com>
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice, this
+//   list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// * Neither the name of the organisation (Microcosm) nor the names of its
+//   contributors may be used to endorse or promote products derived from
+//   this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package bluemonday
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// attrPolicy is the compiled pattern an attribute's value must match,
+// registered either against a specific element or globally.
+type attrPolicy struct {
+	regexp *regexp.Regexp
+}
+
+// attrPolicyBuilder is returned by AllowAttrs and lets the caller restrict
+// a group of attributes to a value pattern and/or a set of elements.
+type attrPolicyBuilder struct {
+	p      *Policy
+	attrs  []string
+	regexp *regexp.Regexp
+}
+
+// Policy describes the allowlist that Sanitize, SanitizeBytes,
+// SanitizeReader and SanitizeStream apply: which elements and attributes
+// survive, which URL schemes and CSS are permitted, and the optional
+// extensions (data URIs, iframes, ID namespacing, scrubbers, auto-linking)
+// configured by the other files in this package. The zero value is a
+// policy that allows nothing; build one with NewPolicy and the various
+// Allow*/Require*/Add* methods.
+type Policy struct {
+	elsAndAttrs map[string]map[string]attrPolicy
+	globalAttrs map[string]attrPolicy
+
+	setOfElementsAllowed map[string]bool
+
+	elsAndStyles map[string]map[string]stylePolicy
+	globalStyles map[string]stylePolicy
+
+	allowURLSchemes      []string
+	requireParseableURLs bool
+	allowRelativeURLs    bool
+	requireNoFollow      bool
+
+	allowDataURIImages bool
+	dataURIMaxBytes    int64
+	dataURIMimeTypes   map[string]bool
+
+	allowIFrames       bool
+	iframeHostPatterns []string
+
+	requireNamespacedIDs    bool
+	idNamespacePrefix       string
+	dropUnknownIDReferences bool
+
+	maxTokenDepth  int
+	maxOutputBytes int64
+
+	elementScrubbers map[string][]ElementScrubberFunc
+	attrScrubbers    map[string][]AttrScrubberFunc
+
+	autoLinkURLs     bool
+	autoLinkMentions MentionResolverFunc
+	autoLinkHashtags HashtagResolverFunc
+}
+
+// NewPolicy returns a new, empty policy that allows nothing until
+// configured via its Allow*/Require*/Add* methods.
+func NewPolicy() *Policy {
+	return &Policy{
+		elsAndAttrs:          map[string]map[string]attrPolicy{},
+		globalAttrs:          map[string]attrPolicy{},
+		setOfElementsAllowed: map[string]bool{},
+		elsAndStyles:         map[string]map[string]stylePolicy{},
+		globalStyles:         map[string]stylePolicy{},
+		dataURIMimeTypes:     map[string]bool{},
+		elementScrubbers:     map[string][]ElementScrubberFunc{},
+		attrScrubbers:        map[string][]AttrScrubberFunc{},
+	}
+}
+
+// AllowAttrs takes a list of attribute names and returns an
+// attrPolicyBuilder used to restrict the values they may take with
+// .Matching(), and the elements they apply to with
+// .OnElements()/.Globally(). An attribute that is never passed to
+// AllowAttrs is stripped from any element it's found on.
+func (p *Policy) AllowAttrs(attrs ...string) *attrPolicyBuilder {
+	return &attrPolicyBuilder{p: p, attrs: attrs}
+}
+
+// Matching allows the attribute values to be matched against a regular
+// expression.
+func (b *attrPolicyBuilder) Matching(regex *regexp.Regexp) *attrPolicyBuilder {
+	b.regexp = regex
+	return b
+}
+
+// OnElements will bind the declared attributes to a given element or
+// elements and return the updated policy. Elements and attributes are
+// lowercased, matching HTML's case-insensitivity, so that e.g.
+// AllowAttrs("Align").OnElements("TD") still matches.
+func (b *attrPolicyBuilder) OnElements(elements ...string) *Policy {
+	for _, element := range elements {
+		element = strings.ToLower(element)
+		if b.p.elsAndAttrs[element] == nil {
+			b.p.elsAndAttrs[element] = map[string]attrPolicy{}
+		}
+		for _, attr := range b.attrs {
+			b.p.elsAndAttrs[element][strings.ToLower(attr)] = attrPolicy{regexp: b.regexp}
+		}
+	}
+	return b.p
+}
+
+// Globally will bind the declared attributes to all elements that are
+// otherwise permitted and return the updated policy.
+func (b *attrPolicyBuilder) Globally() *Policy {
+	for _, attr := range b.attrs {
+		b.p.globalAttrs[strings.ToLower(attr)] = attrPolicy{regexp: b.regexp}
+	}
+	return b.p
+}
+
+// AllowElements permits the named elements, with no attributes other than
+// whatever AllowAttrs/Globally separately allows on them.
+func (p *Policy) AllowElements(names ...string) *Policy {
+	for _, name := range names {
+		p.setOfElementsAllowed[strings.ToLower(name)] = true
+	}
+	return p
+}
+
+// AllowURLSchemes adds to the set of URL schemes (e.g. "https", "mailto")
+// a parseable, absolute URL is allowed to use. Schemes are compared
+// case-insensitively and only consulted when RequireParseableURLs(true)
+// is set.
+func (p *Policy) AllowURLSchemes(schemes ...string) *Policy {
+	for _, scheme := range schemes {
+		p.allowURLSchemes = append(p.allowURLSchemes, strings.ToLower(scheme))
+	}
+	return p
+}
+
+// RequireParseableURLs controls whether a URL-bearing attribute (href,
+// src and similar - see urlAttrs) must parse with net/url.Parse and, if
+// absolute, use one of the schemes registered via AllowURLSchemes. When
+// false (the default) URL values are passed through unexamined, other
+// than "data:" URIs, which are always routed through validDataURI, and
+// the schemes in dangerousURLSchemes, which are always rejected.
+func (p *Policy) RequireParseableURLs(require bool) *Policy {
+	p.requireParseableURLs = require
+	return p
+}
+
+// AllowRelativeURLs controls whether a parseable URL that is not absolute
+// (no scheme) is permitted. Only consulted when RequireParseableURLs(true)
+// is set.
+func (p *Policy) AllowRelativeURLs(allow bool) *Policy {
+	p.allowRelativeURLs = allow
+	return p
+}
+
+// RequireNoFollowOnLinks controls whether a rel="nofollow" is added to
+// every "a", "area" and "link" element that is allowed through, including
+// anchors created by AutoLinkURLs/AutoLinkMentions/AutoLinkHashtags.
+func (p *Policy) RequireNoFollowOnLinks(require bool) *Policy {
+	p.requireNoFollow = require
+	return p
+}
+
+// dangerousURLSchemes are rejected by validURL unconditionally, even when
+// RequireParseableURLs hasn't been set: there's no policy under which a
+// script-executing pseudo-scheme belongs in an href, src or CSS url(...)
+// value, so this isn't left to be opted into.
+var dangerousURLSchemes = map[string]bool{
+	"javascript": true,
+	"vbscript":   true,
+}
+
+// validURL is the single choke point every URL-bearing value passes
+// through, whether it arrived on a url attribute (see urlAttrs), inside a
+// CSS url(...) value, or as a bare URL found by AutoLinkURLs. A "data:"
+// URI is handed off to validDataURI, since its validity depends on
+// AllowDataURIImages rather than on the scheme/relative-URL policy below.
+func (p *Policy) validURL(rawurl string) (string, bool) {
+	if strings.HasPrefix(strings.ToLower(rawurl), "data:") {
+		return p.validDataURI(rawurl)
+	}
+
+	u, err := url.Parse(rawurl)
+	if err == nil && u.IsAbs() && dangerousURLSchemes[strings.ToLower(u.Scheme)] {
+		return "", false
+	}
+
+	if !p.requireParseableURLs {
+		return rawurl, true
+	}
+
+	if err != nil {
+		return "", false
+	}
+
+	if !u.IsAbs() {
+		if p.allowRelativeURLs {
+			return rawurl, true
+		}
+		return "", false
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	for _, allowed := range p.allowURLSchemes {
+		if scheme == allowed {
+			return rawurl, true
+		}
+	}
+	return "", false
+}
+
+// urlAttrs is the fixed set of attributes whose value is a URL, and which
+// is therefore always run through validURL once the attribute has
+// otherwise passed AllowAttrs, regardless of which element it was found
+// on. This mirrors AllowStandardURLs/AllowImages configuring the scheme
+// and relative-URL policy once rather than requiring every caller to
+// attach a URL-validating regexp to every href/src they register.
+var urlAttrs = map[string]bool{
+	"href":       true,
+	"src":        true,
+	"cite":       true,
+	"action":     true,
+	"poster":     true,
+	"background": true,
+	"longdesc":   true,
+}
+
+// linkElements are the elements RequireNoFollowOnLinks adds rel="nofollow"
+// to.
+var linkElements = map[string]bool{
+	"a":    true,
+	"area": true,
+	"link": true,
+}
+
+// elementAllowed reports whether tag may appear in sanitized output at
+// all, i.e. whether it was named in AllowElements or has at least one
+// attribute registered against it via AllowAttrs(...).OnElements(...).
+// Attributes registered via Globally() don't by themselves make an
+// element allowed - they only take effect on elements already allowed by
+// one of these two.
+func (p *Policy) elementAllowed(tag string) bool {
+	if p.setOfElementsAllowed[tag] {
+		return true
+	}
+	_, ok := p.elsAndAttrs[tag]
+	return ok
+}
+
+// attrAllowed reports whether key=val may survive on tag, checking the
+// element-specific pattern registered via OnElements before falling back
+// to the global one registered via Globally.
+func (p *Policy) attrAllowed(tag, key, val string) bool {
+	if policy, ok := p.elsAndAttrs[tag][key]; ok {
+		return policy.regexp == nil || policy.regexp.MatchString(val)
+	}
+	if policy, ok := p.globalAttrs[key]; ok {
+		return policy.regexp == nil || policy.regexp.MatchString(val)
+	}
+	return false
+}
+
+// attrValue returns the value of the first attribute in attrs with the
+// given key, or "" if none is present.
+func attrValue(attrs []html.Attribute, key string) string {
+	for _, a := range attrs {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// ensureRelNoFollow adds rel="nofollow" to attrs, merging it into an
+// existing "rel" value rather than adding a second "rel" attribute, if one
+// isn't already present.
+func ensureRelNoFollow(attrs []html.Attribute) []html.Attribute {
+	for i, a := range attrs {
+		if a.Key != "rel" {
+			continue
+		}
+		for _, tok := range strings.Fields(a.Val) {
+			if strings.EqualFold(tok, "nofollow") {
+				return attrs
+			}
+		}
+		attrs[i].Val = strings.TrimSpace(a.Val + " nofollow")
+		return attrs
+	}
+	return append(attrs, html.Attribute{Key: "rel", Val: "nofollow"})
+}
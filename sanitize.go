@@ -0,0 +1,319 @@
+// Copyright (c) 2014, David Kitchen <david@buro9.com>
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice, this
+//   list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// * Neither the name of the organisation (Microcosm) nor the names of its
+//   contributors may be used to endorse or promote products derived from
+//   this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package bluemonday
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// rawTextElements are dropped, content and all, rather than unwrapped when
+// not explicitly allowed - see processElement.
+var rawTextElements = map[string]bool{
+	"script": true,
+	"style":  true,
+}
+
+// frame records, for one element on tokenProcessor's open-element stack,
+// whether its own start/end tag should be suppressed (isUnwrap) once its
+// end tag is reached, or whether it and everything inside it should be
+// (isDrop).
+type frame struct {
+	tag      string
+	isDrop   bool
+	isUnwrap bool
+}
+
+// tokenProcessor applies the allowlist, CSS/iframe/scrubber handling and
+// auto-linking to one token at a time, carrying just enough state (the
+// open-element stack and the list of ancestor tags) across calls to do so
+// correctly. Both the buffering Sanitize family and the single-pass
+// SanitizeStream drive the same tokenProcessor, so a feature wired in here
+// behaves identically under both.
+type tokenProcessor struct {
+	p         *Policy
+	parents   []string
+	stack     []frame
+	dropDepth int
+}
+
+func newTokenProcessor(p *Policy) *tokenProcessor {
+	return &tokenProcessor{p: p}
+}
+
+// process runs one token through the policy and returns zero or more
+// tokens to emit in its place: usually one, zero if the token was
+// dropped/unwrapped, or several for a text node that auto-linking split
+// into plain-text and anchor segments.
+func (tp *tokenProcessor) process(tok html.Token) []html.Token {
+	switch tok.Type {
+	case html.StartTagToken, html.SelfClosingTagToken:
+		return tp.processTag(tok)
+	case html.EndTagToken:
+		return tp.processEndTag(tok)
+	case html.TextToken:
+		if tp.dropDepth > 0 {
+			return nil
+		}
+		if tp.p.autoLinkEnabled() && !inAutoLinkSkipElement(tp.parents) {
+			return tp.p.autoLinkTokens(tok.Data)
+		}
+		return []html.Token{tok}
+	default: // CommentToken, DoctypeToken: never passed through
+		return nil
+	}
+}
+
+func (tp *tokenProcessor) processTag(tok html.Token) []html.Token {
+	tag := strings.ToLower(tok.Data)
+
+	if tp.dropDepth > 0 {
+		if tok.Type == html.StartTagToken {
+			tp.stack = append(tp.stack, frame{tag: tag})
+			tp.parents = append(tp.parents, tag)
+		}
+		return nil
+	}
+
+	action, outTok := tp.p.processElement(tag, tok, tp.parents)
+
+	if tok.Type == html.StartTagToken {
+		tp.stack = append(tp.stack, frame{tag: tag, isDrop: action == Drop, isUnwrap: action == Unwrap})
+		tp.parents = append(tp.parents, tag)
+		if action == Drop {
+			tp.dropDepth++
+		}
+	}
+
+	if action == Drop || action == Unwrap {
+		return nil
+	}
+	return []html.Token{outTok}
+}
+
+func (tp *tokenProcessor) processEndTag(tok html.Token) []html.Token {
+	tag := strings.ToLower(tok.Data)
+
+	// Close the nearest open element with this tag, along with anything
+	// left unclosed inside it (e.g. a void element such as "br").
+	var top frame
+	matched := false
+	for i := len(tp.stack) - 1; i >= 0 && !matched; i-- {
+		if tp.stack[i].tag != tag {
+			continue
+		}
+		for _, f := range tp.stack[i:] {
+			if f.isDrop {
+				tp.dropDepth--
+			}
+		}
+		top, matched = tp.stack[i], true
+		tp.stack, tp.parents = tp.stack[:i], tp.parents[:i]
+	}
+
+	if tp.dropDepth > 0 {
+		return nil
+	}
+	if matched && (top.isDrop || top.isUnwrap) {
+		return nil
+	}
+	return []html.Token{tok}
+}
+
+// processElement decides what to do with a start/self-closing tag once
+// it's known no ancestor is already being dropped: whether the element is
+// allowed at all, which of its attributes survive AllowAttrs, URL
+// validation and style sanitization, and finally any registered element
+// scrubber. iframe is handled separately, ahead of the general allowlist,
+// because an allowed iframe's attribute set is replaced wholesale by
+// sanitizeIFrameAttrs rather than filtered attribute-by-attribute.
+func (p *Policy) processElement(tag string, tok html.Token, parents []string) (Action, html.Token) {
+	out := html.Token{Type: html.StartTagToken, Data: tag}
+	if tok.Type == html.SelfClosingTagToken {
+		out.Type = html.SelfClosingTagToken
+	}
+
+	if tag == "iframe" {
+		if !p.allowIFrames {
+			return Unwrap, out
+		}
+		src := attrValue(tok.Attr, "src")
+		if _, ok := p.validURL(src); src == "" || !ok || !p.iframeSrcAllowed(src) {
+			return Drop, out
+		}
+		out.Attr = sanitizeIFrameAttrs(src, attrValue(tok.Attr, "width"), attrValue(tok.Attr, "height"))
+		return p.runElementScrubbers(tag, out, parents)
+	}
+
+	if !p.elementAllowed(tag) {
+		// script/style (and any other element whose text content is code
+		// rather than visible prose) must never surface as plain text
+		// just because the tag around it was stripped - Unwrap's "drop
+		// the tag, keep the children" is only safe for elements whose
+		// content is itself text.
+		if rawTextElements[tag] {
+			return Drop, out
+		}
+		return Unwrap, out
+	}
+
+	var kept []html.Attribute
+	for _, a := range tok.Attr {
+		key := strings.ToLower(a.Key)
+		val := a.Val
+
+		if key == "style" {
+			if sanitized, ok := p.sanitizeStyles(val, tag); ok {
+				kept = append(kept, html.Attribute{Key: key, Val: sanitized})
+			}
+			continue
+		}
+
+		if !p.attrAllowed(tag, key, val) {
+			continue
+		}
+
+		if urlAttrs[key] {
+			resolved, ok := p.validURL(val)
+			if !ok {
+				continue
+			}
+			val = resolved
+		}
+
+		attrOut, ok := p.runAttrScrubbers(tag, html.Attribute{Key: key, Val: val}, parents)
+		if !ok {
+			continue
+		}
+		kept = append(kept, attrOut)
+	}
+
+	if p.requireNoFollow && linkElements[tag] {
+		kept = ensureRelNoFollow(kept)
+	}
+
+	out.Attr = kept
+	return p.runElementScrubbers(tag, out, parents)
+}
+
+// runElementScrubbers runs every ElementScrubberFunc registered for tag,
+// in registration order, against an element the built-in allowlist has
+// already decided to keep. A Drop or Unwrap from any scrubber stops the
+// chain; a Replace substitutes the attribute set and continues.
+func (p *Policy) runElementScrubbers(tag string, tok html.Token, parents []string) (Action, html.Token) {
+	for _, fn := range p.elementScrubbers[tag] {
+		result := fn(&ScrubContext{Tag: tag, Attrs: tok.Attr, Parents: parents})
+		switch result.Action {
+		case Drop:
+			return Drop, tok
+		case Unwrap:
+			return Unwrap, tok
+		case Replace:
+			tok.Attr = result.Attrs
+		}
+	}
+	return Keep, tok
+}
+
+// runAttrScrubbers runs every AttrScrubberFunc registered for a.Key, in
+// registration order, against an attribute the built-in allowlist has
+// already decided to keep. ok is false, meaning the attribute should be
+// dropped, once any scrubber returns Drop.
+func (p *Policy) runAttrScrubbers(tag string, a html.Attribute, parents []string) (html.Attribute, bool) {
+	for _, fn := range p.attrScrubbers[a.Key] {
+		result := fn(&AttrContext{Tag: tag, Attr: a, Parents: parents})
+		switch result.Action {
+		case Drop:
+			return a, false
+		case Replace:
+			if len(result.Attrs) > 0 {
+				a = result.Attrs[0]
+			}
+		}
+	}
+	return a, true
+}
+
+// Sanitize applies the policy to an HTML fragment or document given as a
+// string and returns the sanitized result.
+func (p *Policy) Sanitize(s string) string {
+	buf, _ := p.sanitize(strings.NewReader(s))
+	return buf.String()
+}
+
+// SanitizeBytes is Sanitize for a []byte rather than a string.
+func (p *Policy) SanitizeBytes(b []byte) []byte {
+	buf, _ := p.sanitize(bytes.NewReader(b))
+	return buf.Bytes()
+}
+
+// SanitizeReader is Sanitize for an io.Reader, returning the sanitized
+// output as a *bytes.Buffer, along with any error encountered reading r.
+func (p *Policy) SanitizeReader(r io.Reader) (*bytes.Buffer, error) {
+	return p.sanitize(r)
+}
+
+// sanitize tokenizes r, runs every token through tokenProcessor, then -
+// once the full set of surviving tokens for the document is known - runs
+// namespaceIDTokens if NamespaceIDs is enabled, since rewriting a
+// reference to an id needs to know every id defined anywhere in the
+// document, not just the part already processed. That second pass over
+// the whole token stream is exactly what SanitizeStream cannot offer,
+// which is why it refuses a policy with NamespaceIDs enabled instead of
+// producing output that would silently differ from this method's.
+func (p *Policy) sanitize(r io.Reader) (*bytes.Buffer, error) {
+	z := html.NewTokenizer(r)
+	tp := newTokenProcessor(p)
+
+	var kept []html.Token
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if err := z.Err(); err != nil && err != io.EOF {
+				return nil, err
+			}
+			break
+		}
+		kept = append(kept, tp.process(z.Token())...)
+	}
+
+	if p.requireNamespacedIDs {
+		namespaceIDTokens(kept, p.idNamespacePrefix, p.dropUnknownIDReferences)
+	}
+
+	var buf bytes.Buffer
+	for _, tok := range kept {
+		buf.WriteString(tok.String())
+	}
+	return &buf, nil
+}
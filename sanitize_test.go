@@ -0,0 +1,116 @@
+package bluemonday
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestSanitizeEndToEndNamespaceIDs(t *testing.T) {
+	p := NewPolicy()
+	p.AllowElements("span", "a")
+	p.AllowStandardAttributesNamespaced("ns-")
+	p.AllowAttrs("href").OnElements("a")
+
+	out := p.Sanitize(`<span id="a">hi</span><a href="#a">link</a>`)
+
+	want := `<span id="ns-a">hi</span><a href="#ns-a">link</a>`
+	if out != want {
+		t.Errorf("Sanitize() = %q, want %q", out, want)
+	}
+}
+
+func TestSanitizeEndToEndStyles(t *testing.T) {
+	p := NewPolicy()
+	p.AllowElements("div")
+	p.AllowStandardStyles()
+
+	out := p.Sanitize(`<div style="color: red; position: fixed">hi</div>`)
+
+	want := `<div style="color: red">hi</div>`
+	if out != want {
+		t.Errorf("Sanitize() = %q, want %q", out, want)
+	}
+}
+
+func TestSanitizeEndToEndDataURIImages(t *testing.T) {
+	p := NewPolicy()
+	p.AllowAttrs("src").OnElements("img")
+	p.AllowDataURIImages(1 << 20)
+
+	png := "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAQAAAC1HAwCAAAAC0lEQVR42mNk+A8AAQUBAScY42YAAAAASUVORK5CYII="
+
+	out := p.Sanitize(`<img src="` + png + `"/>`)
+
+	want := `<img src="` + png + `"/>`
+	if out != want {
+		t.Errorf("Sanitize() = %q, want %q", out, want)
+	}
+}
+
+func TestSanitizeEndToEndIFrame(t *testing.T) {
+	p := NewPolicy()
+	p.AllowStandardEmbeds()
+
+	out := p.Sanitize(`<iframe src="https://www.youtube.com/embed/dQw4w9WgXcQ" onload="alert(1)"></iframe>`)
+
+	if !strings.Contains(out, `sandbox="allow-scripts allow-presentation"`) {
+		t.Errorf("Sanitize() = %q, want the fixed sandbox value present", out)
+	}
+	if strings.Contains(out, "onload") {
+		t.Errorf("Sanitize() = %q, onload should have been stripped", out)
+	}
+}
+
+func TestSanitizeStreamMatchesSanitize(t *testing.T) {
+	p := NewPolicy()
+	p.AllowElements("b")
+
+	input := `<b>hi</b><script>alert(1)</script>`
+	want := p.Sanitize(input)
+
+	var buf bytes.Buffer
+	if err := p.SanitizeStream(&buf, strings.NewReader(input)); err != nil {
+		t.Fatalf("SanitizeStream() error = %v", err)
+	}
+
+	if buf.String() != want {
+		t.Errorf("SanitizeStream() = %q, want it to match Sanitize() = %q", buf.String(), want)
+	}
+}
+
+func TestSanitizeEndToEndScrubbers(t *testing.T) {
+	p := NewPolicy()
+	p.AllowElements("div")
+	p.AllowAttrs("onclick").OnElements("div")
+	p.AddAttrScrubber("onclick", func(ctx *AttrContext) ScrubResult {
+		return Drop.result()
+	})
+	p.AddElementScrubber("div", func(ctx *ScrubContext) ScrubResult {
+		return Replace.WithAttrs(append(ctx.Attrs, html.Attribute{Key: "data-scrubbed", Val: "1"})...)
+	})
+
+	out := p.Sanitize(`<div onclick="steal()">hi</div>`)
+
+	want := `<div data-scrubbed="1">hi</div>`
+	if out != want {
+		t.Errorf("Sanitize() = %q, want %q", out, want)
+	}
+}
+
+func TestSanitizeEndToEndAutoLinkURLs(t *testing.T) {
+	p := NewPolicy()
+	p.AllowElements("p", "a")
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowStandardURLs()
+	p.AutoLinkURLs()
+
+	out := p.Sanitize(`<p>see https://example.com for more</p>`)
+
+	want := `<p>see <a href="https://example.com" rel="nofollow">https://example.com</a> for more</p>`
+	if out != want {
+		t.Errorf("Sanitize() = %q, want %q", out, want)
+	}
+}
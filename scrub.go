@@ -0,0 +1,135 @@
+// Copyright (c) 2014, David Kitchen <david@buro9.com>
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice, this
+//   list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// * Neither the name of the organisation (Microcosm) nor the names of its
+//   contributors may be used to endorse or promote products derived from
+//   this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package bluemonday
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Action is returned by an ElementScrubberFunc or AttrScrubberFunc to tell
+// Sanitize what to do with the node it was given.
+type Action int
+
+const (
+	// Keep leaves the node as the built-in allowlist already decided.
+	Keep Action = iota
+
+	// Drop removes the element, including its children, or removes the
+	// single attribute under consideration.
+	Drop
+
+	// Unwrap removes the element but keeps its children in its place. It
+	// has no effect for an AttrScrubberFunc.
+	Unwrap
+
+	// Replace substitutes the element's attributes, or the single
+	// attribute under consideration, with the ones carried on
+	// ScrubResult.Attrs. Build a ScrubResult for it with Action.WithAttrs.
+	Replace
+)
+
+// ScrubResult is what an ElementScrubberFunc or AttrScrubberFunc returns:
+// the Action to take, and, for Replace, the attribute set to replace with.
+type ScrubResult struct {
+	Action Action
+	Attrs  []html.Attribute
+}
+
+// WithAttrs pairs the Replace action with the attributes it should replace
+// the current element or attribute with.
+func (a Action) WithAttrs(attrs ...html.Attribute) ScrubResult {
+	return ScrubResult{Action: a, Attrs: attrs}
+}
+
+// result turns a bare Action into a ScrubResult for Keep/Drop/Unwrap, where
+// no attribute payload is needed.
+func (a Action) result() ScrubResult {
+	return ScrubResult{Action: a}
+}
+
+// ScrubContext is handed to an ElementScrubberFunc. It exposes the tag and
+// attribute set the built-in allowlist has already decided to keep for
+// this element, plus the chain of ancestor tags, so a scrubber can make
+// decisions based on where in the document a node sits.
+type ScrubContext struct {
+	Tag     string
+	Attrs   []html.Attribute
+	Parents []string
+}
+
+// AttrContext is handed to an AttrScrubberFunc; it is the ScrubContext
+// narrowed to the single attribute under consideration.
+type AttrContext struct {
+	Tag     string
+	Attr    html.Attribute
+	Parents []string
+}
+
+// ElementScrubberFunc inspects an element that has already survived the
+// built-in allowlist checks and decides whether to keep, drop, unwrap or
+// replace its attributes.
+type ElementScrubberFunc func(ctx *ScrubContext) ScrubResult
+
+// AttrScrubberFunc is the attribute-level equivalent of
+// ElementScrubberFunc.
+type AttrScrubberFunc func(ctx *AttrContext) ScrubResult
+
+// AddElementScrubber registers fn to run, after the built-in allowlist
+// checks, on every surviving element with the given tag. tag is lowercased,
+// matching HTML's case-insensitivity and the convention the rest of the
+// allowlist machinery (e.g. AllowElements) uses, so that
+// AddElementScrubber("DIV", fn) still matches. Scrubbers compose: multiple
+// calls for the same tag all run in registration order, and a Drop or
+// Unwrap from an earlier one skips the rest for that element. Use this for
+// anything the static allowlist can't express, such as adding
+// rel="ugc noopener" to external links, downgrading h1 to h2, or replacing
+// an unrecognised embed with a placeholder. A scrubber only ever sees
+// elements the policy already allowed through; attributes it supplies via
+// Replace are emitted as given, without being checked against the
+// allowlist.
+func (p *Policy) AddElementScrubber(tag string, fn ElementScrubberFunc) *Policy {
+	tag = strings.ToLower(tag)
+	p.elementScrubbers[tag] = append(p.elementScrubbers[tag], fn)
+	return p
+}
+
+// AddAttrScrubber registers fn to run, after the built-in allowlist
+// checks, on every surviving occurrence of the named attribute on any
+// element. attr is lowercased for the same reason as tag in
+// AddElementScrubber. A surviving "style" attribute, and the attributes of
+// an iframe permitted by AllowIFrames, are not passed to attribute
+// scrubbers.
+func (p *Policy) AddAttrScrubber(attr string, fn AttrScrubberFunc) *Policy {
+	attr = strings.ToLower(attr)
+	p.attrScrubbers[attr] = append(p.attrScrubbers[attr], fn)
+	return p
+}
@@ -0,0 +1,32 @@
+package bluemonday
+
+import "testing"
+
+func newScrubPolicy() *Policy {
+	return &Policy{
+		elementScrubbers: map[string][]ElementScrubberFunc{},
+		attrScrubbers:    map[string][]AttrScrubberFunc{},
+	}
+}
+
+func TestAddElementScrubberIsCaseInsensitive(t *testing.T) {
+	p := newScrubPolicy()
+	p.AddElementScrubber("DIV", func(ctx *ScrubContext) ScrubResult {
+		return Keep.result()
+	})
+
+	if len(p.elementScrubbers["div"]) != 1 {
+		t.Errorf("expected scrubber registered under %q to be found under %q, elementScrubbers = %#v", "DIV", "div", p.elementScrubbers)
+	}
+}
+
+func TestAddAttrScrubberIsCaseInsensitive(t *testing.T) {
+	p := newScrubPolicy()
+	p.AddAttrScrubber("ONCLICK", func(ctx *AttrContext) ScrubResult {
+		return Drop.result()
+	})
+
+	if len(p.attrScrubbers["onclick"]) != 1 {
+		t.Errorf("expected scrubber registered under %q to be found under %q, attrScrubbers = %#v", "ONCLICK", "onclick", p.attrScrubbers)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2014, David Kitchen <david@buro9.com>
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// * Redistributions of source code must retain the above copyright notice, this
+//   list of conditions and the following disclaimer.
+//
+// * Redistributions in binary form must reproduce the above copyright notice,
+//   this list of conditions and the following disclaimer in the documentation
+//   and/or other materials provided with the distribution.
+//
+// * Neither the name of the organisation (Microcosm) nor the names of its
+//   contributors may be used to endorse or promote products derived from
+//   this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+// FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+// DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+// SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+// OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package bluemonday
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"golang.org/x/net/html"
+)
+
+// ErrMaxTokenDepthExceeded is returned by SanitizeStream/
+// SanitizeStreamContext when the nesting depth of open elements exceeds
+// MaxTokenDepth.
+var ErrMaxTokenDepthExceeded = errors.New("bluemonday: maximum token depth exceeded")
+
+// ErrMaxOutputBytesExceeded is returned by SanitizeStream/
+// SanitizeStreamContext when the sanitized output would exceed
+// MaxOutputBytes.
+var ErrMaxOutputBytesExceeded = errors.New("bluemonday: maximum output size exceeded")
+
+// ErrStreamingIncompatibleWithNamespaceIDs is returned by SanitizeStream/
+// SanitizeStreamContext when the policy has NamespaceIDs enabled.
+// Namespacing an id-referencing attribute (see NamespaceIDs) needs to know
+// every id defined anywhere in the document before it can decide how to
+// rewrite a reference to one, which a true single-pass, token-ahead stream
+// cannot provide: an id defined later in the document would silently be
+// treated as unknown for any reference that appears before it. Rather than
+// have SanitizeStream produce output that quietly differs from what
+// Sanitize would produce for the same input, it refuses to run at all.
+var ErrStreamingIncompatibleWithNamespaceIDs = errors.New(
+	"bluemonday: SanitizeStream does not support a policy with NamespaceIDs enabled",
+)
+
+// defaultMaxTokenDepth is the nesting depth guard SanitizeStream applies
+// when MaxTokenDepth has not been set, so that a caller who forgets to set
+// it is not left with an unbounded sanitizer fed directly by untrusted,
+// pathologically nested input.
+const defaultMaxTokenDepth = 512
+
+// MaxTokenDepth sets the nesting depth guard used by SanitizeStream and
+// SanitizeStreamContext. A value of 0 (the default) falls back to
+// defaultMaxTokenDepth.
+func (p *Policy) MaxTokenDepth(depth int) *Policy {
+	p.maxTokenDepth = depth
+	return p
+}
+
+// MaxOutputBytes sets the guard SanitizeStream and SanitizeStreamContext
+// use to bound the number of bytes written to their destination writer. A
+// value of 0 (the default) disables the cap.
+func (p *Policy) MaxOutputBytes(max int64) *Policy {
+	p.maxOutputBytes = max
+	return p
+}
+
+// SanitizeStream sanitizes r according to the policy and writes the result
+// to w as tokens are processed, rather than buffering the whole document
+// in memory the way Sanitize, SanitizeBytes and SanitizeReader do. It is
+// intended for pipelines sanitizing large scraped pages or feed bodies,
+// where doubling the document in memory (input plus output buffer) is
+// undesirable.
+//
+// MaxTokenDepth (512 if unset) bounds how deeply elements may nest before
+// sanitization aborts with ErrMaxTokenDepthExceeded. MaxOutputBytes, if
+// set, bounds the total bytes written to w before aborting with
+// ErrMaxOutputBytesExceeded.
+//
+// A policy with NamespaceIDs enabled cannot be streamed this way (see
+// ErrStreamingIncompatibleWithNamespaceIDs) and is rejected immediately.
+func (p *Policy) SanitizeStream(w io.Writer, r io.Reader) error {
+	return p.SanitizeStreamContext(context.Background(), w, r)
+}
+
+// SanitizeStreamContext is SanitizeStream with a context that is checked
+// between tokens, so that a caller can cancel a long-running sanitization,
+// for example because the request that triggered it was itself cancelled.
+func (p *Policy) SanitizeStreamContext(ctx context.Context, w io.Writer, r io.Reader) error {
+	if p.requireNamespacedIDs {
+		return ErrStreamingIncompatibleWithNamespaceIDs
+	}
+
+	maxDepth := p.maxTokenDepth
+	if maxDepth == 0 {
+		maxDepth = defaultMaxTokenDepth
+	}
+
+	cw := &maxBytesWriter{w: w, max: p.maxOutputBytes}
+	z := html.NewTokenizer(r)
+	tp := newTokenProcessor(p)
+	depth := 0
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if err := z.Err(); err != nil && err != io.EOF {
+				return err
+			}
+			return nil
+		}
+
+		switch tt {
+		case html.StartTagToken:
+			depth++
+			if depth > maxDepth {
+				return ErrMaxTokenDepthExceeded
+			}
+		case html.EndTagToken:
+			if depth > 0 {
+				depth--
+			}
+		}
+
+		// tp.process runs the same allowlist, style, iframe, scrubber and
+		// auto-link logic that the buffering Sanitize family applies to
+		// each token, but output is written straight to cw as each token
+		// is produced instead of being appended to an in-memory slice.
+		for _, out := range tp.process(z.Token()) {
+			if _, err := io.WriteString(cw, out.String()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// maxBytesWriter wraps an io.Writer and fails with ErrMaxOutputBytesExceeded
+// once more than max bytes would have been written. max == 0 disables the
+// check.
+type maxBytesWriter struct {
+	w       io.Writer
+	max     int64
+	written int64
+}
+
+func (mw *maxBytesWriter) Write(b []byte) (int, error) {
+	if mw.max > 0 && mw.written+int64(len(b)) > mw.max {
+		return 0, ErrMaxOutputBytesExceeded
+	}
+	n, err := mw.w.Write(b)
+	mw.written += int64(n)
+	return n, err
+}
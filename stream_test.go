@@ -0,0 +1,36 @@
+package bluemonday
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSanitizeStreamRejectsNamespaceIDs(t *testing.T) {
+	p := &Policy{requireNamespacedIDs: true, idNamespacePrefix: "ns-"}
+
+	var buf bytes.Buffer
+	err := p.SanitizeStream(&buf, bytes.NewBufferString(`<p id="a">hi</p>`))
+
+	if !errors.Is(err, ErrStreamingIncompatibleWithNamespaceIDs) {
+		t.Fatalf("SanitizeStream() error = %v, want %v", err, ErrStreamingIncompatibleWithNamespaceIDs)
+	}
+
+	err = p.SanitizeStreamContext(context.Background(), &buf, bytes.NewBufferString(`<p id="a">hi</p>`))
+	if !errors.Is(err, ErrStreamingIncompatibleWithNamespaceIDs) {
+		t.Fatalf("SanitizeStreamContext() error = %v, want %v", err, ErrStreamingIncompatibleWithNamespaceIDs)
+	}
+}
+
+func TestMaxBytesWriterStopsAtCap(t *testing.T) {
+	var buf bytes.Buffer
+	mw := &maxBytesWriter{w: &buf, max: 4}
+
+	if _, err := mw.Write([]byte("abcd")); err != nil {
+		t.Fatalf("unexpected error writing up to the cap: %v", err)
+	}
+	if _, err := mw.Write([]byte("e")); !errors.Is(err, ErrMaxOutputBytesExceeded) {
+		t.Fatalf("Write() past cap error = %v, want %v", err, ErrMaxOutputBytesExceeded)
+	}
+}